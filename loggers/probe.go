@@ -0,0 +1,269 @@
+package loggers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dnsProbe describes one active health-check target, issued on its own
+// ticker in parallel with the passive dnstap collection.
+type dnsProbe struct {
+	name      string
+	address   string
+	transport string
+	qname     string
+	qtype     string
+	interval  time.Duration
+}
+
+// InitProbes registers the probe-related metric families. It is a no-op
+// when no probes are configured, so deployments that don't use active
+// probing pay no extra cardinality.
+func (o *Prometheus) InitProbes() {
+	if len(o.config.Loggers.Prometheus.Probes) == 0 {
+		return
+	}
+
+	o.gaugeProbeUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: fmt.Sprintf("%s_probe_up", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "Whether the last probe succeeded (1) or failed (0)",
+		},
+		[]string{"probe"},
+	)
+	o.promRegistry.MustRegister(o.gaugeProbeUp)
+
+	o.histogramProbeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    fmt.Sprintf("%s_probe_duration_seconds", o.config.Loggers.Prometheus.PromPrefix),
+			Help:    "Duration of each phase of a probe",
+			Buckets: []float64{0.001, 0.005, 0.010, 0.050, 0.100, 0.5, 1.0},
+		},
+		[]string{"probe", "phase"},
+	)
+	o.promRegistry.MustRegister(o.histogramProbeDuration)
+
+	o.counterProbeRcode = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_probe_rcode_total", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "Number of probe responses, partitioned by rcode",
+		},
+		[]string{"probe", "rcode"},
+	)
+	o.promRegistry.MustRegister(o.counterProbeRcode)
+
+	o.gaugeProbeAnswerCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: fmt.Sprintf("%s_probe_answer_count", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "Number of records in the last probe's answer section",
+		},
+		[]string{"probe"},
+	)
+	o.promRegistry.MustRegister(o.gaugeProbeAnswerCount)
+}
+
+// RunProbes starts one goroutine per configured probe, each issuing active
+// DNS queries on its own interval until o.done_probes is closed by Stop().
+func (o *Prometheus) RunProbes() {
+	if len(o.config.Loggers.Prometheus.Probes) == 0 {
+		return
+	}
+
+	for _, cfg := range o.config.Loggers.Prometheus.Probes {
+		p := dnsProbe{
+			name:      cfg.Name,
+			address:   cfg.Address,
+			transport: cfg.Transport,
+			qname:     dns.Fqdn(cfg.Qname),
+			qtype:     cfg.Qtype,
+			interval:  time.Duration(cfg.Interval) * time.Second,
+		}
+		if p.interval <= 0 {
+			p.interval = 30 * time.Second
+		}
+
+		go o.runProbeLoop(p)
+	}
+}
+
+func (o *Prometheus) runProbeLoop(p dnsProbe) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	// probe once immediately so gauges aren't empty until the first tick
+	o.runProbeOnce(p)
+
+	for {
+		select {
+		case <-ticker.C:
+			o.runProbeOnce(p)
+		case <-o.done_probes:
+			return
+		}
+	}
+}
+
+// runProbeOnce issues a single DNS query against p and records the
+// connect/tls_handshake/query phase durations plus the outcome.
+func (o *Prometheus) runProbeOnce(p dnsProbe) {
+	if p.transport == "doh" {
+		o.runProbeDoH(p)
+		return
+	}
+
+	qtype, ok := dns.StringToType[p.qtype]
+	if !ok {
+		qtype = dns.TypeA
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(p.qname, qtype)
+	msg.RecursionDesired = true
+
+	client := &dns.Client{Timeout: 5 * time.Second}
+
+	// dial the raw transport first so "connect" times only the TCP/UDP
+	// handshake; TLS, when used, is timed as its own separate phase below
+	dialNet := "udp"
+	if p.transport == "tcp" || p.transport == "dot" {
+		dialNet = "tcp"
+	}
+
+	connectStart := time.Now()
+	rawConn, err := net.DialTimeout(dialNet, p.address, client.Timeout)
+	o.histogramProbeDuration.WithLabelValues(p.name, "connect").Observe(time.Since(connectStart).Seconds())
+	if err != nil {
+		o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+		return
+	}
+	defer rawConn.Close()
+
+	var netConn net.Conn = rawConn
+	if p.transport == "dot" {
+		tlsConn := tls.Client(rawConn, &tls.Config{ServerName: probeHost(p.address)})
+
+		tlsStart := time.Now()
+		err = tlsConn.Handshake()
+		o.histogramProbeDuration.WithLabelValues(p.name, "tls_handshake").Observe(time.Since(tlsStart).Seconds())
+		if err != nil {
+			o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+			return
+		}
+		netConn = tlsConn
+	}
+
+	conn := &dns.Conn{Conn: netConn}
+
+	queryStart := time.Now()
+	reply, _, err := client.ExchangeWithConn(msg, conn)
+	o.histogramProbeDuration.WithLabelValues(p.name, "query").Observe(time.Since(queryStart).Seconds())
+
+	if err != nil || reply == nil {
+		o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+		return
+	}
+
+	o.recordProbeReply(p, reply)
+}
+
+// runProbeDoH issues the probe query over DNS-over-HTTPS, the one
+// transport the miekg/dns client doesn't speak natively. Since
+// http.Client.Do hides connect/TLS/wait behind a single call, the phases
+// are timed with an httptrace.ClientTrace instead of wall-clock around Do.
+func (o *Prometheus) runProbeDoH(p dnsProbe) {
+	qtype, ok := dns.StringToType[p.qtype]
+	if !ok {
+		qtype = dns.TypeA
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(p.qname, qtype)
+	msg.RecursionDesired = true
+
+	packed, err := msg.Pack()
+	if err != nil {
+		o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+		return
+	}
+
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+
+	req, err := http.NewRequest(http.MethodPost, p.address, bytes.NewReader(packed))
+	if err != nil {
+		o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+		return
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+
+	start := time.Now()
+	var tlsStart, firstByteAt time.Time
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			o.histogramProbeDuration.WithLabelValues(p.name, "connect").Observe(time.Since(start).Seconds())
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			if !tlsStart.IsZero() {
+				o.histogramProbeDuration.WithLabelValues(p.name, "tls_handshake").Observe(time.Since(tlsStart).Seconds())
+			}
+		},
+		GotFirstResponseByte: func() {
+			firstByteAt = time.Now()
+			o.histogramProbeDuration.WithLabelValues(p.name, "first_byte").Observe(time.Since(start).Seconds())
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+		return
+	}
+	defer resp.Body.Close()
+
+	if firstByteAt.IsZero() {
+		firstByteAt = time.Now()
+	}
+	body, err := io.ReadAll(resp.Body)
+	o.histogramProbeDuration.WithLabelValues(p.name, "query").Observe(time.Since(firstByteAt).Seconds())
+	if err != nil {
+		o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+		return
+	}
+
+	reply := new(dns.Msg)
+	if err := reply.Unpack(body); err != nil {
+		o.gaugeProbeUp.WithLabelValues(p.name).Set(0)
+		return
+	}
+
+	o.recordProbeReply(p, reply)
+}
+
+func (o *Prometheus) recordProbeReply(p dnsProbe, reply *dns.Msg) {
+	o.gaugeProbeUp.WithLabelValues(p.name).Set(1)
+	o.counterProbeRcode.WithLabelValues(p.name, dns.RcodeToString[reply.Rcode]).Inc()
+	o.gaugeProbeAnswerCount.WithLabelValues(p.name).Set(float64(len(reply.Answer)))
+}
+
+// probeHost strips the port from a "host:port" address for use as a TLS
+// ServerName.
+func probeHost(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
@@ -1,6 +1,7 @@
 package loggers
 
 import (
+	"crypto/subtle"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -8,6 +9,8 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dmachard/go-dnscollector/dnsutils"
@@ -25,8 +28,15 @@ type EpsCounters struct {
 }
 
 type Prometheus struct {
+	// mu guards every map field below: Record(), ComputeEps() and
+	// rotateWindows() mutate them from the Run goroutine while the
+	// /streams, /streams/{id}/top and /reset handlers read (and, for
+	// /reset, reassign) them from the HTTP server goroutine
+	mu sync.RWMutex
+
 	done         chan bool
 	done_api     chan bool
+	done_probes  chan bool
 	httpserver   net.Listener
 	channel      chan dnsutils.DnsMessage
 	config       *dnsutils.Config
@@ -46,6 +56,48 @@ type Prometheus struct {
 	domainsUniq    map[string]int
 	nxdomainsUniq  map[string]int
 
+	// approximate cardinality mode: replaces the exact maps above with
+	// bounded-memory sketches when Loggers.Prometheus.ApproxCardinality is set
+	approxCardinality bool
+
+	cmsDomains    map[string]*CountMinSketch
+	cmsNxdomains  map[string]*CountMinSketch
+	cmsRequesters map[string]*CountMinSketch
+
+	ssDomains    map[string]*StreamSummary
+	ssNxdomains  map[string]*StreamSummary
+	ssRequesters map[string]*StreamSummary
+
+	// counterDomains/Nx/Requesters estimate distinct-per-stream counts in
+	// approx mode; StreamSummary.Offer's isNew signal isn't a valid proxy
+	// for this (it also fires on evict-then-reappear churn), so a per-stream
+	// HLL feeds these the same way the global HLLs below feed the Uniq counters
+	hllDomains        map[string]*HyperLogLog
+	hllDomainsPrev    map[string]uint64
+	hllNxdomains      map[string]*HyperLogLog
+	hllNxdomainsPrev  map[string]uint64
+	hllRequesters     map[string]*HyperLogLog
+	hllRequestersPrev map[string]uint64
+
+	// counterDomainsUniq/NxUniq/RequestersUniq count uniqueness globally
+	// (no stream_id label), so a single HLL per kind feeds them in approx
+	// mode — one per identity would double-count qnames seen on several streams
+	hllDomainsGlobal        *HyperLogLog
+	hllDomainsGlobalPrev    uint64
+	hllNxdomainsGlobal      *HyperLogLog
+	hllNxdomainsGlobalPrev  uint64
+	hllRequestersGlobal     *HyperLogLog
+	hllRequestersGlobalPrev uint64
+
+	// sliding-window mode: ages per-stream top-N counts out over
+	// Loggers.Prometheus.WindowSeconds instead of accumulating forever
+	windowEnabled    bool
+	windowSeconds    int
+	windowBuckets    int
+	windowDomains    map[string]*SlidingWindowCounter
+	windowNxdomains  map[string]*SlidingWindowCounter
+	windowRequesters map[string]*SlidingWindowCounter
+
 	streamsMap map[string]*EpsCounters
 
 	gaugeBuildInfo     *prometheus.GaugeVec
@@ -56,7 +108,20 @@ type Prometheus struct {
 	gaugeEps    *prometheus.GaugeVec
 	gaugeEpsMax *prometheus.GaugeVec
 
+	// legacyLabels gates the old, high-cardinality counterPackets metric;
+	// new deployments should rely on the focused vectors below instead
+	legacyLabels bool
+
+	// bearerToken is loaded once at startup from BearerTokenFile; empty
+	// means bearer-token auth is disabled
+	bearerToken string
+
 	counterPackets     *prometheus.CounterVec
+	counterQueries     *prometheus.CounterVec
+	counterResponses   *prometheus.CounterVec
+	counterMalformed   *prometheus.CounterVec
+	counterTruncated   *prometheus.CounterVec
+	counterEdnsOptions *prometheus.CounterVec
 	totalReceivedBytes *prometheus.CounterVec
 	totalSentBytes     *prometheus.CounterVec
 
@@ -68,11 +133,23 @@ type Prometheus struct {
 	counterDomainsNxUniq  *prometheus.CounterVec
 	counterRequestersUniq *prometheus.CounterVec
 
+	// gaugeDomainsFreqEstimate publishes the Count-Min Sketch frequency
+	// estimate for each stream's current top-K domains; only registered
+	// when ApproxCardinality is enabled
+	gaugeDomainsFreqEstimate *prometheus.GaugeVec
+
 	histogramQueriesLength *prometheus.HistogramVec
 	histogramRepliesLength *prometheus.HistogramVec
 	histogramQnamesLength  *prometheus.HistogramVec
 	histogramLatencies     *prometheus.HistogramVec
 
+	// active health-probe subsystem, see probe.go; nil vecs until InitProbes
+	// registers them, which only happens when probes are configured
+	gaugeProbeUp           *prometheus.GaugeVec
+	histogramProbeDuration *prometheus.HistogramVec
+	counterProbeRcode      *prometheus.CounterVec
+	gaugeProbeAnswerCount  *prometheus.GaugeVec
+
 	name string
 }
 
@@ -81,6 +158,7 @@ func NewPrometheus(config *dnsutils.Config, logger *logger.Logger, version strin
 	o := &Prometheus{
 		done:         make(chan bool),
 		done_api:     make(chan bool),
+		done_probes:  make(chan bool),
 		config:       config,
 		channel:      make(chan dnsutils.DnsMessage, 512),
 		logger:       logger,
@@ -99,15 +177,58 @@ func NewPrometheus(config *dnsutils.Config, logger *logger.Logger, version strin
 		domainsUniq:    make(map[string]int),
 		nxdomainsUniq:  make(map[string]int),
 
+		approxCardinality: config.Loggers.Prometheus.ApproxCardinality,
+
+		cmsDomains:    make(map[string]*CountMinSketch),
+		cmsNxdomains:  make(map[string]*CountMinSketch),
+		cmsRequesters: make(map[string]*CountMinSketch),
+
+		ssDomains:    make(map[string]*StreamSummary),
+		ssNxdomains:  make(map[string]*StreamSummary),
+		ssRequesters: make(map[string]*StreamSummary),
+
+		hllDomains:        make(map[string]*HyperLogLog),
+		hllDomainsPrev:    make(map[string]uint64),
+		hllNxdomains:      make(map[string]*HyperLogLog),
+		hllNxdomainsPrev:  make(map[string]uint64),
+		hllRequesters:     make(map[string]*HyperLogLog),
+		hllRequestersPrev: make(map[string]uint64),
+
+		hllDomainsGlobal:    NewHyperLogLog(14),
+		hllNxdomainsGlobal:  NewHyperLogLog(14),
+		hllRequestersGlobal: NewHyperLogLog(14),
+
+		windowEnabled: config.Loggers.Prometheus.WindowSeconds > 0,
+		windowSeconds: config.Loggers.Prometheus.WindowSeconds,
+		// 10 buckets gives a reasonable window/recency trade-off without
+		// making each bucket's rotation interval too coarse
+		windowBuckets:    10,
+		windowDomains:    make(map[string]*SlidingWindowCounter),
+		windowNxdomains:  make(map[string]*SlidingWindowCounter),
+		windowRequesters: make(map[string]*SlidingWindowCounter),
+
+		legacyLabels: config.Loggers.Prometheus.LegacyLabels,
+
 		streamsMap: make(map[string]*EpsCounters),
 
 		name: name,
 	}
 	o.InitProm()
+	o.InitProbes()
 
 	// add build version in metrics
 	o.gaugeBuildInfo.WithLabelValues(o.version).Set(1)
 
+	// load the bearer token once at startup so Record()/ListenAndServe()
+	// never touch disk on the hot path
+	if config.Loggers.Prometheus.BearerTokenFile != "" {
+		token, err := ioutil.ReadFile(config.Loggers.Prometheus.BearerTokenFile)
+		if err != nil {
+			logger.Fatal("loading bearer token file failed:", err)
+		}
+		o.bearerToken = strings.TrimSpace(string(token))
+	}
+
 	return o
 }
 
@@ -121,12 +242,20 @@ func (o *Prometheus) InitProm() {
 	)
 	o.promRegistry.MustRegister(o.gaugeBuildInfo)
 
+	// the sliding-window mode ages counts out over Loggers.Prometheus.WindowSeconds
+	// instead of accumulating them forever; expose the window length as a
+	// label so both cumulative and windowed deployments can be told apart in PromQL
+	topLabels := []string{"stream_id", "domain"}
+	if o.windowEnabled {
+		topLabels = []string{"stream_id", "domain", "window_seconds"}
+	}
+
 	o.gaugeTopDomains = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: fmt.Sprintf("%s_top_domains_total", o.config.Loggers.Prometheus.PromPrefix),
 			Help: "Number of hit per domain topN, partitioned by qname",
 		},
-		[]string{"stream_id", "domain"},
+		topLabels,
 	)
 	o.promRegistry.MustRegister(o.gaugeTopDomains)
 
@@ -135,7 +264,7 @@ func (o *Prometheus) InitProm() {
 			Name: fmt.Sprintf("%s_top_nxdomains_total", o.config.Loggers.Prometheus.PromPrefix),
 			Help: "Number of hit per nx domain topN, partitioned by qname",
 		},
-		[]string{"stream_id", "domain"},
+		topLabels,
 	)
 	o.promRegistry.MustRegister(o.gaugeTopNxDomains)
 
@@ -144,7 +273,7 @@ func (o *Prometheus) InitProm() {
 			Name: fmt.Sprintf("%s_top_requesters_total", o.config.Loggers.Prometheus.PromPrefix),
 			Help: "Number of hit per requester topN, partitioned by qname",
 		},
-		[]string{"stream_id", "domain"},
+		topLabels,
 	)
 	o.promRegistry.MustRegister(o.gaugeTopRequesters)
 
@@ -166,27 +295,76 @@ func (o *Prometheus) InitProm() {
 	)
 	o.promRegistry.MustRegister(o.gaugeEpsMax)
 
-	o.counterPackets = prometheus.NewCounterVec(
+	// legacy, high-cardinality metric kept only for users still on old
+	// dashboards; new deployments should leave LegacyLabels unset
+	if o.legacyLabels {
+		o.counterPackets = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("%s_packets_count", o.config.Loggers.Prometheus.PromPrefix),
+				Help: "Counter of packets",
+			},
+			[]string{
+				"stream_id",
+				"net_family",
+				"net_transport",
+				"op_name",
+				"op_code",
+				"return_code",
+				"query_type",
+				"flag_qr",
+				"flag_tc",
+				"flag_aa",
+				"flag_ra",
+				"flag_ad",
+				"pkt_err"},
+		)
+		o.promRegistry.MustRegister(o.counterPackets)
+	}
+
+	o.counterQueries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_queries_total", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "The total number of queries",
+		},
+		[]string{"stream_id", "qtype", "family", "transport"},
+	)
+	o.promRegistry.MustRegister(o.counterQueries)
+
+	o.counterResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_responses_total", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "The total number of responses",
+		},
+		[]string{"stream_id", "rcode", "qtype"},
+	)
+	o.promRegistry.MustRegister(o.counterResponses)
+
+	o.counterMalformed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_malformed_total", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "The total number of malformed packets",
+		},
+		[]string{"stream_id"},
+	)
+	o.promRegistry.MustRegister(o.counterMalformed)
+
+	o.counterTruncated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: fmt.Sprintf("%s_truncated_total", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "The total number of truncated packets",
+		},
+		[]string{"stream_id"},
+	)
+	o.promRegistry.MustRegister(o.counterTruncated)
+
+	o.counterEdnsOptions = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: fmt.Sprintf("%s_packets_count", o.config.Loggers.Prometheus.PromPrefix),
-			Help: "Counter of packets",
+			Name: fmt.Sprintf("%s_edns_options_total", o.config.Loggers.Prometheus.PromPrefix),
+			Help: "The total number of EDNS options seen, partitioned by option code",
 		},
-		[]string{
-			"stream_id",
-			"net_family",
-			"net_transport",
-			"op_name",
-			"op_code",
-			"return_code",
-			"query_type",
-			"flag_qr",
-			"flag_tc",
-			"flag_aa",
-			"flag_ra",
-			"flag_ad",
-			"pkt_err"},
+		[]string{"stream_id", "option_code"},
 	)
-	o.promRegistry.MustRegister(o.counterPackets)
+	o.promRegistry.MustRegister(o.counterEdnsOptions)
 
 	o.histogramQueriesLength = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -299,6 +477,19 @@ func (o *Prometheus) InitProm() {
 		[]string{},
 	)
 	o.promRegistry.MustRegister(o.counterRequestersUniq)
+
+	// the Count-Min Sketch only exists in approximate cardinality mode, so
+	// only pay for this metric's cardinality when it has something to report
+	if o.approxCardinality {
+		o.gaugeDomainsFreqEstimate = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: fmt.Sprintf("%s_domains_freq_estimate", o.config.Loggers.Prometheus.PromPrefix),
+				Help: "Count-Min Sketch estimated frequency for each stream's current top-K domains",
+			},
+			[]string{"stream_id", "domain"},
+		)
+		o.promRegistry.MustRegister(o.gaugeDomainsFreqEstimate)
+	}
 }
 
 func (o *Prometheus) ReadConfig() {
@@ -334,19 +525,243 @@ func (o *Prometheus) Stop() {
 	<-o.done_api
 	close(o.done_api)
 
+	// stop the active probe goroutines, if any were started
+	if len(o.config.Loggers.Prometheus.Probes) > 0 {
+		close(o.done_probes)
+	}
+
 	o.LogInfo(" stopped")
 }
 
-/*func (o *Prometheus) BasicAuth(w http.ResponseWriter, r *http.Request) bool {
+// BasicAuth reports whether the request carries the configured
+// BasicAuthLogin/BasicAuthPwd credentials, compared in constant time to
+// avoid leaking timing information about the expected value.
+func (o *Prometheus) BasicAuth(r *http.Request) bool {
 	login, password, authOK := r.BasicAuth()
 	if !authOK {
 		return false
 	}
 
-	return (login == o.config.Loggers.Prometheus.BasicAuthLogin) && (password == o.config.Loggers.Prometheus.BasicAuthPwd)
-}*/
+	loginOK := subtle.ConstantTimeCompare([]byte(login), []byte(o.config.Loggers.Prometheus.BasicAuthLogin)) == 1
+	pwdOK := subtle.ConstantTimeCompare([]byte(password), []byte(o.config.Loggers.Prometheus.BasicAuthPwd)) == 1
+
+	return loginOK && pwdOK
+}
+
+// BearerAuth reports whether the request carries a valid
+// "Authorization: Bearer <token>" header, compared against the token
+// loaded at startup from BearerTokenFile.
+func (o *Prometheus) BearerAuth(r *http.Request) bool {
+	if o.bearerToken == "" {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, prefix)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(o.bearerToken)) == 1
+}
+
+// authMiddleware enforces BasicAuth and/or BearerAuth, when configured,
+// in front of next. A request is admitted if it satisfies either scheme
+// that is enabled; if neither is enabled the request passes through.
+func (o *Prometheus) authMiddleware(next http.Handler) http.Handler {
+	basicEnabled := o.config.Loggers.Prometheus.BasicAuthLogin != "" || o.config.Loggers.Prometheus.BasicAuthPwd != ""
+	bearerEnabled := o.bearerToken != ""
+
+	if !basicEnabled && !bearerEnabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if basicEnabled && o.BasicAuth(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if bearerEnabled && o.BearerAuth(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="prometheus metrics"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// sketchWidth/sketchDepth/sketchTopK fall back to sane defaults when the
+// Sketch block is left unconfigured.
+func (o *Prometheus) sketchWidth() uint32 {
+	if o.config.Loggers.Prometheus.Sketch.Width > 0 {
+		return uint32(o.config.Loggers.Prometheus.Sketch.Width)
+	}
+	return 2048
+}
+
+func (o *Prometheus) sketchDepth() uint32 {
+	if o.config.Loggers.Prometheus.Sketch.Depth > 0 {
+		return uint32(o.config.Loggers.Prometheus.Sketch.Depth)
+	}
+	return 4
+}
+
+func (o *Prometheus) sketchTopK() int {
+	if o.config.Loggers.Prometheus.Sketch.TopK > 0 {
+		return o.config.Loggers.Prometheus.Sketch.TopK
+	}
+	return o.config.Loggers.Prometheus.TopN
+}
+
+// approxTopLabelValues builds the label values for gaugeTopDomains et al.
+// InitProm adds a trailing "window_seconds" label whenever WindowSeconds is
+// also set, so the approx path has to match that shape or
+// WithLabelValues panics with inconsistent label cardinality.
+func (o *Prometheus) approxTopLabelValues(identity, name string) []string {
+	if o.windowEnabled {
+		return []string{identity, name, strconv.Itoa(o.windowSeconds)}
+	}
+	return []string{identity, name}
+}
+
+// recordDomainApprox feeds the qname into the per-stream Count-Min Sketch,
+// Space-Saving summary and HyperLogLog instead of the exact maps, bounding
+// memory use regardless of the number of distinct qnames observed.
+func (o *Prometheus) recordDomainApprox(dm dnsutils.DnsMessage) {
+	identity := dm.DnsTap.Identity
+
+	if _, ok := o.cmsDomains[identity]; !ok {
+		o.cmsDomains[identity] = NewCountMinSketch(o.sketchWidth(), o.sketchDepth())
+		o.ssDomains[identity] = NewStreamSummary(o.sketchTopK())
+		o.hllDomains[identity] = NewHyperLogLog(14)
+	}
+
+	o.cmsDomains[identity].Add(dm.DNS.Qname)
+	o.ssDomains[identity].Offer(dm.DNS.Qname)
+
+	// counterDomains estimates distinct domains per stream; Offer's isNew
+	// signal also fires on evict-then-reappear churn, so it can't stand in
+	// for distinctness the way it can for the top-K summary itself
+	o.hllDomains[identity].Add(dm.DNS.Qname)
+	if streamEstimate := o.hllDomains[identity].Estimate(); streamEstimate > o.hllDomainsPrev[identity] {
+		o.counterDomains.WithLabelValues(identity).Add(float64(streamEstimate - o.hllDomainsPrev[identity]))
+		o.hllDomainsPrev[identity] = streamEstimate
+	}
+
+	o.hllDomainsGlobal.Add(dm.DNS.Qname)
+
+	// counterDomainsUniq is a Counter, so nudge it forward by the delta
+	// between HyperLogLog estimates instead of setting an absolute value
+	estimate := o.hllDomainsGlobal.Estimate()
+	if estimate > o.hllDomainsGlobalPrev {
+		o.counterDomainsUniq.WithLabelValues().Add(float64(estimate - o.hllDomainsGlobalPrev))
+		o.hllDomainsGlobalPrev = estimate
+	}
+
+	o.gaugeTopDomains.Reset()
+	if o.gaugeDomainsFreqEstimate != nil {
+		o.gaugeDomainsFreqEstimate.Reset()
+	}
+	for _, e := range o.ssDomains[identity].Top(o.sketchTopK()) {
+		o.gaugeTopDomains.WithLabelValues(o.approxTopLabelValues(identity, e.key)...).Set(float64(e.count))
+		if o.gaugeDomainsFreqEstimate != nil {
+			o.gaugeDomainsFreqEstimate.WithLabelValues(identity, e.key).Set(float64(o.cmsDomains[identity].Estimate(e.key)))
+		}
+	}
+}
+
+// recordNxdomainApprox mirrors recordDomainApprox for NXDOMAIN responses.
+func (o *Prometheus) recordNxdomainApprox(dm dnsutils.DnsMessage) {
+	identity := dm.DnsTap.Identity
+
+	if _, ok := o.cmsNxdomains[identity]; !ok {
+		o.cmsNxdomains[identity] = NewCountMinSketch(o.sketchWidth(), o.sketchDepth())
+		o.ssNxdomains[identity] = NewStreamSummary(o.sketchTopK())
+		o.hllNxdomains[identity] = NewHyperLogLog(14)
+	}
+
+	o.cmsNxdomains[identity].Add(dm.DNS.Qname)
+	o.ssNxdomains[identity].Offer(dm.DNS.Qname)
+
+	o.hllNxdomains[identity].Add(dm.DNS.Qname)
+	if streamEstimate := o.hllNxdomains[identity].Estimate(); streamEstimate > o.hllNxdomainsPrev[identity] {
+		o.counterDomainsNx.WithLabelValues(identity).Add(float64(streamEstimate - o.hllNxdomainsPrev[identity]))
+		o.hllNxdomainsPrev[identity] = streamEstimate
+	}
+
+	o.hllNxdomainsGlobal.Add(dm.DNS.Qname)
+	estimate := o.hllNxdomainsGlobal.Estimate()
+	if estimate > o.hllNxdomainsGlobalPrev {
+		o.counterDomainsNxUniq.WithLabelValues().Add(float64(estimate - o.hllNxdomainsGlobalPrev))
+		o.hllNxdomainsGlobalPrev = estimate
+	}
+
+	o.gaugeTopNxDomains.Reset()
+	for _, e := range o.ssNxdomains[identity].Top(o.sketchTopK()) {
+		o.gaugeTopNxDomains.WithLabelValues(o.approxTopLabelValues(identity, e.key)...).Set(float64(e.count))
+	}
+}
+
+// recordRequesterApprox mirrors recordDomainApprox for client IPs.
+func (o *Prometheus) recordRequesterApprox(dm dnsutils.DnsMessage) {
+	identity := dm.DnsTap.Identity
+
+	if _, ok := o.cmsRequesters[identity]; !ok {
+		o.cmsRequesters[identity] = NewCountMinSketch(o.sketchWidth(), o.sketchDepth())
+		o.ssRequesters[identity] = NewStreamSummary(o.sketchTopK())
+		o.hllRequesters[identity] = NewHyperLogLog(14)
+	}
+
+	o.cmsRequesters[identity].Add(dm.NetworkInfo.QueryIp)
+	o.ssRequesters[identity].Offer(dm.NetworkInfo.QueryIp)
+
+	o.hllRequesters[identity].Add(dm.NetworkInfo.QueryIp)
+	if streamEstimate := o.hllRequesters[identity].Estimate(); streamEstimate > o.hllRequestersPrev[identity] {
+		o.counterRequesters.WithLabelValues(identity).Add(float64(streamEstimate - o.hllRequestersPrev[identity]))
+		o.hllRequestersPrev[identity] = streamEstimate
+	}
+
+	o.hllRequestersGlobal.Add(dm.NetworkInfo.QueryIp)
+	estimate := o.hllRequestersGlobal.Estimate()
+	if estimate > o.hllRequestersGlobalPrev {
+		o.counterRequestersUniq.WithLabelValues().Add(float64(estimate - o.hllRequestersGlobalPrev))
+		o.hllRequestersGlobalPrev = estimate
+	}
+
+	o.gaugeTopRequesters.Reset()
+	for _, e := range o.ssRequesters[identity].Top(o.sketchTopK()) {
+		o.gaugeTopRequesters.WithLabelValues(o.approxTopLabelValues(identity, e.key)...).Set(float64(e.count))
+	}
+}
+
+// recordWindowedTop records key into identity's sliding-window counter
+// (creating it on first use) and republishes the window's top-N into gauge.
+func (o *Prometheus) recordWindowedTop(windows map[string]*SlidingWindowCounter, gauge *prometheus.GaugeVec, identity string, key string) {
+	if _, ok := windows[identity]; !ok {
+		windows[identity] = NewSlidingWindowCounter(o.windowBuckets)
+	}
+	windows[identity].Record(key)
+
+	top := topmap.NewTopMap(o.config.Loggers.Prometheus.TopN)
+	for name, hits := range windows[identity].All() {
+		top.Record(name, hits)
+	}
+
+	gauge.Reset()
+	windowLabel := strconv.Itoa(o.windowSeconds)
+	for _, r := range top.Get() {
+		gauge.WithLabelValues(identity, r.Name, windowLabel).Set(float64(r.Hit))
+	}
+}
 
 func (o *Prometheus) Record(dm dnsutils.DnsMessage) {
+	// guards every map below against concurrent access from the HTTP
+	// inspection handlers in prometheus_api.go
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	// record stream identity
 	if _, exists := o.streamsMap[dm.DnsTap.Identity]; !exists {
 		o.streamsMap[dm.DnsTap.Identity] = new(EpsCounters)
@@ -355,23 +770,53 @@ func (o *Prometheus) Record(dm dnsutils.DnsMessage) {
 		o.streamsMap[dm.DnsTap.Identity].TotalEvents += 1
 	}
 
-	// count number of logs according to the stream name
-	//o.counterPackets.WithLabelValues(dm.DnsTap.Identity).Inc()
-	o.counterPackets.WithLabelValues(
-		dm.DnsTap.Identity,
-		dm.NetworkInfo.Family,
-		dm.NetworkInfo.Protocol,
-		dm.DnsTap.Operation,
-		strconv.Itoa(dm.DNS.Opcode),
-		dm.DNS.Rcode,
-		dm.DNS.Qtype,
-		dm.DNS.Type,
-		strconv.FormatBool(dm.DNS.Flags.TC),
-		strconv.FormatBool(dm.DNS.Flags.AA),
-		strconv.FormatBool(dm.DNS.Flags.RA),
-		strconv.FormatBool(dm.DNS.Flags.AD),
-		strconv.FormatBool(dm.DNS.MalformedPacket),
-	).Inc()
+	// legacy, high-cardinality metric; only fed when LegacyLabels is set
+	if o.legacyLabels {
+		o.counterPackets.WithLabelValues(
+			dm.DnsTap.Identity,
+			dm.NetworkInfo.Family,
+			dm.NetworkInfo.Protocol,
+			dm.DnsTap.Operation,
+			strconv.Itoa(dm.DNS.Opcode),
+			dm.DNS.Rcode,
+			dm.DNS.Qtype,
+			dm.DNS.Type,
+			strconv.FormatBool(dm.DNS.Flags.TC),
+			strconv.FormatBool(dm.DNS.Flags.AA),
+			strconv.FormatBool(dm.DNS.Flags.RA),
+			strconv.FormatBool(dm.DNS.Flags.AD),
+			strconv.FormatBool(dm.DNS.MalformedPacket),
+		).Inc()
+	}
+
+	// focused metric families, one per concern, to keep cardinality and
+	// PromQL queries manageable
+	if dm.DNS.Type == dnsutils.DnsQuery {
+		o.counterQueries.WithLabelValues(
+			dm.DnsTap.Identity,
+			dm.DNS.Qtype,
+			dm.NetworkInfo.Family,
+			dm.NetworkInfo.Protocol,
+		).Inc()
+	} else {
+		o.counterResponses.WithLabelValues(
+			dm.DnsTap.Identity,
+			dm.DNS.Rcode,
+			dm.DNS.Qtype,
+		).Inc()
+	}
+
+	if dm.DNS.MalformedPacket {
+		o.counterMalformed.WithLabelValues(dm.DnsTap.Identity).Inc()
+	}
+
+	if dm.DNS.Flags.TC {
+		o.counterTruncated.WithLabelValues(dm.DnsTap.Identity).Inc()
+	}
+
+	for _, opt := range dm.EDNS.Options {
+		o.counterEdnsOptions.WithLabelValues(dm.DnsTap.Identity, strconv.Itoa(opt.Code)).Inc()
+	}
 
 	// count the number of queries and replies
 	// count the total bytes for queries and replies
@@ -392,6 +837,17 @@ func (o *Prometheus) Record(dm dnsutils.DnsMessage) {
 		o.histogramLatencies.WithLabelValues(dm.DnsTap.Identity).Observe(dm.DnsTap.Latency)
 	}
 
+	// when approximate cardinality mode is on, bounded-memory sketches
+	// replace the exact maps below entirely
+	if o.approxCardinality {
+		o.recordDomainApprox(dm)
+		if dm.DNS.Rcode == "NXDOMAIN" {
+			o.recordNxdomainApprox(dm)
+		}
+		o.recordRequesterApprox(dm)
+		return
+	}
+
 	/* count all domains name and top domains */
 	if _, exists := o.domainsUniq[dm.DNS.Qname]; !exists {
 		o.domainsUniq[dm.DNS.Qname] = 1
@@ -411,14 +867,18 @@ func (o *Prometheus) Record(dm dnsutils.DnsMessage) {
 		o.domains[dm.DnsTap.Identity][dm.DNS.Qname] += 1
 	}
 
-	if _, ok := o.topDomains[dm.DnsTap.Identity]; !ok {
-		o.topDomains[dm.DnsTap.Identity] = topmap.NewTopMap(o.config.Loggers.Prometheus.TopN)
-	}
-	o.topDomains[dm.DnsTap.Identity].Record(dm.DNS.Qname, o.domains[dm.DnsTap.Identity][dm.DNS.Qname])
+	if o.windowEnabled {
+		o.recordWindowedTop(o.windowDomains, o.gaugeTopDomains, dm.DnsTap.Identity, dm.DNS.Qname)
+	} else {
+		if _, ok := o.topDomains[dm.DnsTap.Identity]; !ok {
+			o.topDomains[dm.DnsTap.Identity] = topmap.NewTopMap(o.config.Loggers.Prometheus.TopN)
+		}
+		o.topDomains[dm.DnsTap.Identity].Record(dm.DNS.Qname, o.domains[dm.DnsTap.Identity][dm.DNS.Qname])
 
-	o.gaugeTopDomains.Reset()
-	for _, r := range o.topDomains[dm.DnsTap.Identity].Get() {
-		o.gaugeTopDomains.WithLabelValues(dm.DnsTap.Identity, r.Name).Set(float64(r.Hit))
+		o.gaugeTopDomains.Reset()
+		for _, r := range o.topDomains[dm.DnsTap.Identity].Get() {
+			o.gaugeTopDomains.WithLabelValues(dm.DnsTap.Identity, r.Name).Set(float64(r.Hit))
+		}
 	}
 
 	/* record and count all nx domains name and topN*/
@@ -440,14 +900,18 @@ func (o *Prometheus) Record(dm dnsutils.DnsMessage) {
 			o.nxdomains[dm.DnsTap.Identity][dm.DNS.Qname] += 1
 		}
 
-		if _, ok := o.topNxDomains[dm.DnsTap.Identity]; !ok {
-			o.topNxDomains[dm.DnsTap.Identity] = topmap.NewTopMap(o.config.Loggers.Prometheus.TopN)
-		}
-		o.topNxDomains[dm.DnsTap.Identity].Record(dm.DNS.Qname, o.domains[dm.DnsTap.Identity][dm.DNS.Qname])
+		if o.windowEnabled {
+			o.recordWindowedTop(o.windowNxdomains, o.gaugeTopNxDomains, dm.DnsTap.Identity, dm.DNS.Qname)
+		} else {
+			if _, ok := o.topNxDomains[dm.DnsTap.Identity]; !ok {
+				o.topNxDomains[dm.DnsTap.Identity] = topmap.NewTopMap(o.config.Loggers.Prometheus.TopN)
+			}
+			o.topNxDomains[dm.DnsTap.Identity].Record(dm.DNS.Qname, o.nxdomains[dm.DnsTap.Identity][dm.DNS.Qname])
 
-		o.gaugeTopNxDomains.Reset()
-		for _, r := range o.topNxDomains[dm.DnsTap.Identity].Get() {
-			o.gaugeTopNxDomains.WithLabelValues(dm.DnsTap.Identity, r.Name).Set(float64(r.Hit))
+			o.gaugeTopNxDomains.Reset()
+			for _, r := range o.topNxDomains[dm.DnsTap.Identity].Get() {
+				o.gaugeTopNxDomains.WithLabelValues(dm.DnsTap.Identity, r.Name).Set(float64(r.Hit))
+			}
 		}
 	}
 
@@ -469,18 +933,41 @@ func (o *Prometheus) Record(dm dnsutils.DnsMessage) {
 		o.requesters[dm.DnsTap.Identity][dm.NetworkInfo.QueryIp] += 1
 	}
 
-	if _, ok := o.topRequesters[dm.DnsTap.Identity]; !ok {
-		o.topRequesters[dm.DnsTap.Identity] = topmap.NewTopMap(o.config.Loggers.Prometheus.TopN)
+	if o.windowEnabled {
+		o.recordWindowedTop(o.windowRequesters, o.gaugeTopRequesters, dm.DnsTap.Identity, dm.NetworkInfo.QueryIp)
+	} else {
+		if _, ok := o.topRequesters[dm.DnsTap.Identity]; !ok {
+			o.topRequesters[dm.DnsTap.Identity] = topmap.NewTopMap(o.config.Loggers.Prometheus.TopN)
+		}
+		o.topRequesters[dm.DnsTap.Identity].Record(dm.NetworkInfo.QueryIp, o.requesters[dm.DnsTap.Identity][dm.NetworkInfo.QueryIp])
+
+		o.gaugeTopRequesters.Reset()
+		for _, r := range o.topRequesters[dm.DnsTap.Identity].Get() {
+			o.gaugeTopRequesters.WithLabelValues(dm.DnsTap.Identity, r.Name).Set(float64(r.Hit))
+		}
 	}
-	o.topRequesters[dm.DnsTap.Identity].Record(dm.DNS.Qname, o.domains[dm.DnsTap.Identity][dm.DNS.Qname])
+}
 
-	o.gaugeTopRequesters.Reset()
-	for _, r := range o.topRequesters[dm.DnsTap.Identity].Get() {
-		o.gaugeTopRequesters.WithLabelValues(dm.DnsTap.Identity, r.Name).Set(float64(r.Hit))
+// rotateWindows advances every tracked sliding-window counter by one bucket.
+func (o *Prometheus) rotateWindows() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, w := range o.windowDomains {
+		w.Rotate()
+	}
+	for _, w := range o.windowNxdomains {
+		w.Rotate()
+	}
+	for _, w := range o.windowRequesters {
+		w.Rotate()
 	}
 }
 
 func (o *Prometheus) ComputeEps() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
 	// for each stream compute the number of events per second
 	for stream := range o.streamsMap {
 		// compute number of events per second
@@ -504,7 +991,13 @@ func (s *Prometheus) ListenAndServe() {
 
 	mux := http.NewServeMux()
 
-	mux.Handle("/metrics", promhttp.HandlerFor(s.promRegistry, promhttp.HandlerOpts{}))
+	mux.Handle("/metrics", s.authMiddleware(promhttp.HandlerFor(s.promRegistry, promhttp.HandlerOpts{})))
+
+	// ad-hoc JSON inspection endpoints, usable as a lightweight standalone
+	// dashboard backend without requiring Prometheus+Grafana
+	mux.Handle("/streams", s.authMiddleware(http.HandlerFunc(s.handleStreams)))
+	mux.Handle("/streams/", s.authMiddleware(http.HandlerFunc(s.handleStreamTop)))
+	mux.Handle("/reset", s.authMiddleware(http.HandlerFunc(s.handleReset)))
 
 	var err error
 	var listener net.Listener
@@ -565,12 +1058,51 @@ func (s *Prometheus) Run() {
 	// start http server
 	go s.ListenAndServe()
 
+	// start active DNS health probes, if any are configured
+	s.RunProbes()
+
 	// init timer to compute qps
 	t1_interval := 1 * time.Second
 	t1 := time.NewTimer(t1_interval)
 
+	// init timer to rotate the sliding-window buckets, aging old hits out
+	// of the top-N gauges
+	var t2 *time.Timer
+	var t2_interval time.Duration
+	if s.windowEnabled {
+		t2_interval = time.Duration(s.windowSeconds) * time.Second / time.Duration(s.windowBuckets)
+		t2 = time.NewTimer(t2_interval)
+	}
+
 LOOP:
 	for {
+		if s.windowEnabled {
+			select {
+			case dm, opened := <-s.channel:
+				if !opened {
+					s.LogInfo("channel closed")
+					break LOOP
+				}
+				// record the dnstap message
+				s.Record(dm)
+
+			case <-t1.C:
+				// compute eps each second
+				s.ComputeEps()
+
+				// reset the timer
+				t1.Reset(t1_interval)
+
+			case <-t2.C:
+				// age the sliding-window buckets out
+				s.rotateWindows()
+
+				// reset the timer
+				t2.Reset(t2_interval)
+			}
+			continue
+		}
+
 		select {
 		case dm, opened := <-s.channel:
 			if !opened {
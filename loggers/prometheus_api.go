@@ -0,0 +1,224 @@
+package loggers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dmachard/go-topmap"
+)
+
+// TopEntry is one ranked entry in a stream's top-N domains/nxdomains/requesters.
+type TopEntry struct {
+	Name string `json:"name"`
+	Hit  int    `json:"hit"`
+}
+
+// StreamInfo is the JSON representation of one stream_id's current state,
+// returned by GET /streams.
+type StreamInfo struct {
+	StreamID      string     `json:"stream_id"`
+	Eps           uint64     `json:"eps"`
+	EpsMax        uint64     `json:"eps_max"`
+	TotalEvents   uint64     `json:"total_events"`
+	TopDomains    []TopEntry `json:"top_domains"`
+	TopNxDomains  []TopEntry `json:"top_nxdomains"`
+	TopRequesters []TopEntry `json:"top_requesters"`
+}
+
+// defaultTopN is used when the `n` query parameter is absent or invalid.
+const defaultTopN = 10
+
+func topNFromQuery(r *http.Request) int {
+	if raw := r.URL.Query().Get("n"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultTopN
+}
+
+// topEntries returns the ranked top-N entries for one stream/kind pair,
+// reading from whichever backing store (exact topmap, sliding window, or
+// approximate Space-Saving summary) is active for this logger instance.
+func (o *Prometheus) topEntries(kind string, identity string, n int) []TopEntry {
+	switch kind {
+	case "domain":
+		if o.approxCardinality {
+			return ssTop(o.ssDomains[identity], n)
+		}
+		if o.windowEnabled {
+			return windowTop(o.windowDomains[identity], n)
+		}
+		return topmapTop(o.topDomains[identity], n)
+	case "nxdomain":
+		if o.approxCardinality {
+			return ssTop(o.ssNxdomains[identity], n)
+		}
+		if o.windowEnabled {
+			return windowTop(o.windowNxdomains[identity], n)
+		}
+		return topmapTop(o.topNxDomains[identity], n)
+	case "requester":
+		if o.approxCardinality {
+			return ssTop(o.ssRequesters[identity], n)
+		}
+		if o.windowEnabled {
+			return windowTop(o.windowRequesters[identity], n)
+		}
+		return topmapTop(o.topRequesters[identity], n)
+	default:
+		return nil
+	}
+}
+
+func topmapTop(tm *topmap.TopMap, n int) []TopEntry {
+	if tm == nil {
+		return nil
+	}
+	entries := make([]TopEntry, 0, n)
+	for _, r := range tm.Get() {
+		entries = append(entries, TopEntry{Name: r.Name, Hit: r.Hit})
+		if len(entries) == n {
+			break
+		}
+	}
+	return entries
+}
+
+func ssTop(ss *StreamSummary, n int) []TopEntry {
+	if ss == nil {
+		return nil
+	}
+	entries := make([]TopEntry, 0, n)
+	for _, e := range ss.Top(n) {
+		entries = append(entries, TopEntry{Name: e.key, Hit: int(e.count)})
+	}
+	return entries
+}
+
+func windowTop(w *SlidingWindowCounter, n int) []TopEntry {
+	if w == nil {
+		return nil
+	}
+	totals := w.All()
+	entries := make([]TopEntry, 0, len(totals))
+	for name, hits := range totals {
+		entries = append(entries, TopEntry{Name: name, Hit: hits})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Hit > entries[j].Hit })
+	if n > 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// handleStreams serves GET /streams: a JSON snapshot of every known
+// stream_id's counters and top-N domains/nxdomains/requesters.
+func (o *Prometheus) handleStreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n := topNFromQuery(r)
+
+	o.mu.RLock()
+	streams := make([]StreamInfo, 0, len(o.streamsMap))
+	for identity, counters := range o.streamsMap {
+		streams = append(streams, StreamInfo{
+			StreamID:      identity,
+			Eps:           counters.Eps,
+			EpsMax:        counters.EpsMax,
+			TotalEvents:   counters.TotalEvents,
+			TopDomains:    o.topEntries("domain", identity, n),
+			TopNxDomains:  o.topEntries("nxdomain", identity, n),
+			TopRequesters: o.topEntries("requester", identity, n),
+		})
+	}
+	o.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(streams)
+}
+
+// handleStreamTop serves GET /streams/{id}/top?kind=domain|nxdomain|requester&n=...
+func (o *Prometheus) handleStreamTop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identity := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/streams/"), "/top")
+	if identity == "" {
+		http.Error(w, "missing stream id", http.StatusBadRequest)
+		return
+	}
+
+	kind := r.URL.Query().Get("kind")
+	switch kind {
+	case "domain", "nxdomain", "requester":
+	default:
+		http.Error(w, "kind must be one of domain, nxdomain, requester", http.StatusBadRequest)
+		return
+	}
+
+	o.mu.RLock()
+	entries := o.topEntries(kind, identity, topNFromQuery(r))
+	o.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// handleReset serves POST /reset: clears all per-stream state. It is
+// destructive, so it is always wrapped in authMiddleware by the caller.
+func (o *Prometheus) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.streamsMap = make(map[string]*EpsCounters)
+
+	o.domains = make(map[string]map[string]int)
+	o.nxdomains = make(map[string]map[string]int)
+	o.requesters = make(map[string]map[string]int)
+
+	o.topDomains = make(map[string]*topmap.TopMap)
+	o.topNxDomains = make(map[string]*topmap.TopMap)
+	o.topRequesters = make(map[string]*topmap.TopMap)
+
+	o.cmsDomains = make(map[string]*CountMinSketch)
+	o.cmsNxdomains = make(map[string]*CountMinSketch)
+	o.cmsRequesters = make(map[string]*CountMinSketch)
+
+	o.ssDomains = make(map[string]*StreamSummary)
+	o.ssNxdomains = make(map[string]*StreamSummary)
+	o.ssRequesters = make(map[string]*StreamSummary)
+
+	o.windowDomains = make(map[string]*SlidingWindowCounter)
+	o.windowNxdomains = make(map[string]*SlidingWindowCounter)
+	o.windowRequesters = make(map[string]*SlidingWindowCounter)
+
+	o.hllDomains = make(map[string]*HyperLogLog)
+	o.hllDomainsPrev = make(map[string]uint64)
+	o.hllNxdomains = make(map[string]*HyperLogLog)
+	o.hllNxdomainsPrev = make(map[string]uint64)
+	o.hllRequesters = make(map[string]*HyperLogLog)
+	o.hllRequestersPrev = make(map[string]uint64)
+
+	o.hllDomainsGlobal = NewHyperLogLog(14)
+	o.hllDomainsGlobalPrev = 0
+	o.hllNxdomainsGlobal = NewHyperLogLog(14)
+	o.hllNxdomainsGlobalPrev = 0
+	o.hllRequestersGlobal = NewHyperLogLog(14)
+	o.hllRequestersGlobalPrev = 0
+
+	w.WriteHeader(http.StatusOK)
+}
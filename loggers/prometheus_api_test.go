@@ -0,0 +1,113 @@
+package loggers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dmachard/go-logger"
+)
+
+func newAPITestLogger() *Prometheus {
+	return NewPrometheus(newTestConfig(false), logger.New(false), "1.0.0", "test")
+}
+
+func TestHandleStreamsReturnsRecordedStreams(t *testing.T) {
+	o := newAPITestLogger()
+	o.Record(newTestDnsMessage("stream1", "example.com.", "A"))
+	o.Record(newTestDnsMessage("stream1", "example.com.", "A"))
+	o.Record(newTestDnsMessage("stream1", "other.com.", "A"))
+
+	req := httptest.NewRequest(http.MethodGet, "/streams", nil)
+	rr := httptest.NewRecorder()
+	o.handleStreams(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var streams []StreamInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &streams); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("len(streams) = %d, want 1", len(streams))
+	}
+	if streams[0].StreamID != "stream1" || streams[0].TotalEvents != 3 {
+		t.Fatalf("streams[0] = %+v, want stream1/TotalEvents=3", streams[0])
+	}
+}
+
+func TestHandleStreamsRejectsWrongMethod(t *testing.T) {
+	o := newAPITestLogger()
+
+	req := httptest.NewRequest(http.MethodPost, "/streams", nil)
+	rr := httptest.NewRecorder()
+	o.handleStreams(rr, req)
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandleStreamTopReturnsRankedDomains(t *testing.T) {
+	o := newAPITestLogger()
+	for i := 0; i < 3; i++ {
+		o.Record(newTestDnsMessage("stream1", "hot.com.", "A"))
+	}
+	o.Record(newTestDnsMessage("stream1", "cold.com.", "A"))
+
+	req := httptest.NewRequest(http.MethodGet, "/streams/stream1/top?kind=domain", nil)
+	rr := httptest.NewRecorder()
+	o.handleStreamTop(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var entries []TopEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(entries) == 0 || entries[0].Name != "hot.com." || entries[0].Hit != 3 {
+		t.Fatalf("entries[0] = %+v, want hot.com./3 ranked first", entries)
+	}
+}
+
+func TestHandleStreamTopRejectsInvalidKind(t *testing.T) {
+	o := newAPITestLogger()
+
+	req := httptest.NewRequest(http.MethodGet, "/streams/stream1/top?kind=bogus", nil)
+	rr := httptest.NewRecorder()
+	o.handleStreamTop(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleResetClearsStreamState(t *testing.T) {
+	o := newAPITestLogger()
+	o.Record(newTestDnsMessage("stream1", "example.com.", "A"))
+
+	req := httptest.NewRequest(http.MethodPost, "/reset", nil)
+	rr := httptest.NewRecorder()
+	o.handleReset(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	streamsReq := httptest.NewRequest(http.MethodGet, "/streams", nil)
+	streamsRR := httptest.NewRecorder()
+	o.handleStreams(streamsRR, streamsReq)
+
+	var streams []StreamInfo
+	if err := json.Unmarshal(streamsRR.Body.Bytes(), &streams); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(streams) != 0 {
+		t.Fatalf("len(streams) after reset = %d, want 0", len(streams))
+	}
+}
@@ -0,0 +1,121 @@
+package loggers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dmachard/go-logger"
+)
+
+func newAuthTestLogger(login, password, bearerToken string) *Prometheus {
+	config := newTestConfig(false)
+	config.Loggers.Prometheus.BasicAuthLogin = login
+	config.Loggers.Prometheus.BasicAuthPwd = password
+
+	o := NewPrometheus(config, logger.New(false), "1.0.0", "test")
+	o.bearerToken = bearerToken
+	return o
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// TestAuthMiddlewareDisabledPassesThrough verifies that when neither
+// BasicAuth nor BearerAuth is configured, requests reach the handler
+// unauthenticated.
+func TestAuthMiddlewareDisabledPassesThrough(t *testing.T) {
+	o := newAuthTestLogger("", "", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+// TestAuthMiddlewareBasicAuth verifies correct credentials are admitted and
+// wrong ones are rejected with 401 once BasicAuth is configured.
+func TestAuthMiddlewareBasicAuth(t *testing.T) {
+	o := newAuthTestLogger("admin", "secret", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("correct credentials: status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	badReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	badReq.SetBasicAuth("admin", "wrong")
+	badRR := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(badRR, badReq)
+	if badRR.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong credentials: status = %d, want %d", badRR.Code, http.StatusUnauthorized)
+	}
+
+	noAuthReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	noAuthRR := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(noAuthRR, noAuthReq)
+	if noAuthRR.Code != http.StatusUnauthorized {
+		t.Fatalf("no credentials: status = %d, want %d", noAuthRR.Code, http.StatusUnauthorized)
+	}
+}
+
+// TestAuthMiddlewareBearerAuth verifies the bearer token path independently
+// of BasicAuth, including rejection of a wrong token.
+func TestAuthMiddlewareBearerAuth(t *testing.T) {
+	o := newAuthTestLogger("", "", "s3cr3t-token")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t-token")
+	rr := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("correct token: status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	badReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	badReq.Header.Set("Authorization", "Bearer wrong-token")
+	badRR := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(badRR, badReq)
+	if badRR.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: status = %d, want %d", badRR.Code, http.StatusUnauthorized)
+	}
+}
+
+// TestAuthMiddlewareEitherSchemeAdmits verifies that when both BasicAuth and
+// BearerAuth are configured, a request satisfying either one is admitted —
+// the two schemes are additive, not both-required.
+func TestAuthMiddlewareEitherSchemeAdmits(t *testing.T) {
+	o := newAuthTestLogger("admin", "secret", "s3cr3t-token")
+
+	basicReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	basicReq.SetBasicAuth("admin", "secret")
+	basicRR := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(basicRR, basicReq)
+	if basicRR.Code != http.StatusOK {
+		t.Fatalf("basic auth with both schemes configured: status = %d, want %d", basicRR.Code, http.StatusOK)
+	}
+
+	bearerReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	bearerReq.Header.Set("Authorization", "Bearer s3cr3t-token")
+	bearerRR := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(bearerRR, bearerReq)
+	if bearerRR.Code != http.StatusOK {
+		t.Fatalf("bearer auth with both schemes configured: status = %d, want %d", bearerRR.Code, http.StatusOK)
+	}
+
+	neitherReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	neitherRR := httptest.NewRecorder()
+	o.authMiddleware(okHandler()).ServeHTTP(neitherRR, neitherReq)
+	if neitherRR.Code != http.StatusUnauthorized {
+		t.Fatalf("no credentials with both schemes configured: status = %d, want %d", neitherRR.Code, http.StatusUnauthorized)
+	}
+}
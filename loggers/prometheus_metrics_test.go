@@ -0,0 +1,89 @@
+package loggers
+
+import (
+	"testing"
+
+	"github.com/dmachard/go-dnscollector/dnsutils"
+	"github.com/dmachard/go-logger"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestConfig(legacyLabels bool) *dnsutils.Config {
+	config := &dnsutils.Config{}
+	config.Loggers.Prometheus.PromPrefix = "dnscollector"
+	config.Loggers.Prometheus.TopN = 10
+	config.Loggers.Prometheus.LegacyLabels = legacyLabels
+	return config
+}
+
+func newTestDnsMessage(identity, qname, qtype string) dnsutils.DnsMessage {
+	dm := dnsutils.DnsMessage{}
+	dm.DnsTap.Identity = identity
+	dm.NetworkInfo.Family = "INET"
+	dm.NetworkInfo.Protocol = "UDP"
+	dm.DNS.Type = dnsutils.DnsQuery
+	dm.DNS.Qname = qname
+	dm.DNS.Qtype = qtype
+	dm.DNS.Rcode = "NOERROR"
+	return dm
+}
+
+// TestFocusedMetricFamiliesAlwaysRegistered verifies the split queries_total
+// family is registered and fed with LegacyLabels off, so dashboards built on
+// it do not depend on the legacy counter being enabled.
+func TestFocusedMetricFamiliesAlwaysRegistered(t *testing.T) {
+	o := NewPrometheus(newTestConfig(false), logger.New(false), "1.0.0", "test")
+
+	o.Record(newTestDnsMessage("stream1", "example.com.", "A"))
+
+	if got := testutil.ToFloat64(o.counterQueries.WithLabelValues("stream1", "A", "INET", "UDP")); got != 1 {
+		t.Fatalf("counterQueries = %v, want 1", got)
+	}
+}
+
+// TestLegacyCounterPacketsGatedByConfig verifies the high-cardinality
+// counterPackets family is only registered, and only fed, when LegacyLabels
+// is enabled.
+func TestLegacyCounterPacketsGatedByConfig(t *testing.T) {
+	withoutLegacy := NewPrometheus(newTestConfig(false), logger.New(false), "1.0.0", "test")
+	if withoutLegacy.counterPackets != nil {
+		t.Fatalf("counterPackets should be nil when LegacyLabels is false")
+	}
+
+	withLegacy := NewPrometheus(newTestConfig(true), logger.New(false), "1.0.0", "test")
+	if withLegacy.counterPackets == nil {
+		t.Fatalf("counterPackets should be registered when LegacyLabels is true")
+	}
+
+	withLegacy.Record(newTestDnsMessage("stream1", "example.com.", "A"))
+
+	if got := testutil.CollectAndCount(withLegacy.counterPackets); got != 1 {
+		t.Fatalf("counterPackets series count = %d, want 1", got)
+	}
+}
+
+// TestMalformedAndTruncatedCountersOnlyFireOnFlag verifies the focused
+// malformed/truncated families only increment for messages that actually
+// set the corresponding flag, unlike the old single counterPackets which
+// labeled every packet regardless.
+func TestMalformedAndTruncatedCountersOnlyFireOnFlag(t *testing.T) {
+	o := NewPrometheus(newTestConfig(false), logger.New(false), "1.0.0", "test")
+
+	clean := newTestDnsMessage("stream1", "example.com.", "A")
+	o.Record(clean)
+
+	malformed := newTestDnsMessage("stream1", "example.com.", "A")
+	malformed.DNS.MalformedPacket = true
+	o.Record(malformed)
+
+	truncated := newTestDnsMessage("stream1", "example.com.", "A")
+	truncated.DNS.Flags.TC = true
+	o.Record(truncated)
+
+	if got := testutil.ToFloat64(o.counterMalformed.WithLabelValues("stream1")); got != 1 {
+		t.Fatalf("counterMalformed = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(o.counterTruncated.WithLabelValues("stream1")); got != 1 {
+		t.Fatalf("counterTruncated = %v, want 1", got)
+	}
+}
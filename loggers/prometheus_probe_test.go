@@ -0,0 +1,170 @@
+package loggers
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestProbeMetrics builds the probe metric vectors directly, without
+// going through InitProbes/config, so probe tests don't need to know the
+// shape of the Loggers.Prometheus.Probes config slice.
+func newTestProbeMetrics() *Prometheus {
+	o := &Prometheus{promRegistry: prometheus.NewRegistry()}
+
+	o.gaugeProbeUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "test_probe_up", Help: "test"},
+		[]string{"probe"},
+	)
+	o.histogramProbeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: "test_probe_duration_seconds", Help: "test"},
+		[]string{"probe", "phase"},
+	)
+	o.counterProbeRcode = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "test_probe_rcode_total", Help: "test"},
+		[]string{"probe", "rcode"},
+	)
+	o.gaugeProbeAnswerCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "test_probe_answer_count", Help: "test"},
+		[]string{"probe"},
+	)
+
+	o.promRegistry.MustRegister(o.gaugeProbeUp, o.histogramProbeDuration, o.counterProbeRcode, o.gaugeProbeAnswerCount)
+	return o
+}
+
+// startTestDNSServer starts a UDP miekg/dns server that always answers with
+// a single A record, and returns its address and a stop function.
+func startTestDNSServer(t *testing.T) (addr string, stop func()) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		reply := new(dns.Msg)
+		reply.SetReply(req)
+		rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 127.0.0.1")
+		reply.Answer = append(reply.Answer, rr)
+		w.WriteMsg(reply)
+	})
+
+	srv := &dns.Server{PacketConn: conn, Handler: mux}
+	go srv.ActivateAndServe()
+
+	return conn.LocalAddr().String(), func() { srv.Shutdown() }
+}
+
+// TestRunProbeOnceUDPSuccess verifies a successful probe marks the probe up,
+// records the query/connect phase histograms, and tallies the reply rcode.
+func TestRunProbeOnceUDPSuccess(t *testing.T) {
+	addr, stop := startTestDNSServer(t)
+	defer stop()
+
+	o := newTestProbeMetrics()
+	p := dnsProbe{name: "resolver1", address: addr, transport: "udp", qname: "example.com.", qtype: "A"}
+
+	o.runProbeOnce(p)
+
+	if got := testutil.ToFloat64(o.gaugeProbeUp.WithLabelValues("resolver1")); got != 1 {
+		t.Fatalf("gaugeProbeUp = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(o.counterProbeRcode.WithLabelValues("resolver1", "NOERROR")); got != 1 {
+		t.Fatalf("counterProbeRcode{NOERROR} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(o.gaugeProbeAnswerCount.WithLabelValues("resolver1")); got != 1 {
+		t.Fatalf("gaugeProbeAnswerCount = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(o.histogramProbeDuration); got != 2 {
+		t.Fatalf("histogramProbeDuration series count = %d, want 2 (connect, query)", got)
+	}
+}
+
+// TestRunProbeOnceTCPConnectFailure verifies a probe against a closed TCP
+// port marks the probe down instead of panicking or hanging. TCP is used
+// (rather than UDP) so the connection is refused immediately instead of
+// waiting out the full query timeout.
+func TestRunProbeOnceTCPConnectFailure(t *testing.T) {
+	o := newTestProbeMetrics()
+
+	// bind to a free port, then close it so the port is immediately refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	p := dnsProbe{name: "resolver1", address: addr, transport: "tcp", qname: "example.com.", qtype: "A"}
+
+	done := make(chan struct{})
+	go func() {
+		o.runProbeOnce(p)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runProbeOnce did not return in time")
+	}
+
+	if got := testutil.ToFloat64(o.gaugeProbeUp.WithLabelValues("resolver1")); got != 0 {
+		t.Fatalf("gaugeProbeUp = %v, want 0", got)
+	}
+}
+
+// TestRunProbeDoHSuccess verifies a successful DoH probe marks the probe up
+// and records distinct connect/first_byte/query phase histograms via
+// httptrace, instead of collapsing the whole round-trip into "connect".
+func TestRunProbeDoHSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("read request body: %v", err)
+		}
+		req := new(dns.Msg)
+		if err := req.Unpack(body); err != nil {
+			t.Fatalf("unpack request: %v", err)
+		}
+
+		reply := new(dns.Msg)
+		reply.SetReply(req)
+		rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 127.0.0.1")
+		reply.Answer = append(reply.Answer, rr)
+
+		packed, err := reply.Pack()
+		if err != nil {
+			t.Fatalf("pack reply: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer server.Close()
+
+	o := newTestProbeMetrics()
+	p := dnsProbe{name: "resolver1", address: server.URL, transport: "doh", qname: "example.com.", qtype: "A"}
+
+	o.runProbeDoH(p)
+
+	if got := testutil.ToFloat64(o.gaugeProbeUp.WithLabelValues("resolver1")); got != 1 {
+		t.Fatalf("gaugeProbeUp = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(o.gaugeProbeAnswerCount.WithLabelValues("resolver1")); got != 1 {
+		t.Fatalf("gaugeProbeAnswerCount = %v, want 1", got)
+	}
+
+	if got := testutil.CollectAndCount(o.histogramProbeDuration); got != 3 {
+		t.Fatalf("histogramProbeDuration series count = %d, want 3 (connect, first_byte, query)", got)
+	}
+}
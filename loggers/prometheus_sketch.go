@@ -0,0 +1,204 @@
+package loggers
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// CountMinSketch is a fixed-size, probabilistic frequency counter.
+// It trades exactness for a constant memory footprint of Depth*Width
+// uint32 counters, regardless of the number of distinct keys observed.
+type CountMinSketch struct {
+	width uint32
+	depth uint32
+	seeds []uint32
+	table [][]uint32
+}
+
+func NewCountMinSketch(width, depth uint32) *CountMinSketch {
+	if width == 0 {
+		width = 1
+	}
+	if depth == 0 {
+		depth = 1
+	}
+
+	seeds := make([]uint32, depth)
+	table := make([][]uint32, depth)
+	for i := uint32(0); i < depth; i++ {
+		// cheap but sufficiently independent seeds for a streaming metrics counter
+		seeds[i] = (i+1)*2654435761 + 1
+		table[i] = make([]uint32, width)
+	}
+
+	return &CountMinSketch{width: width, depth: depth, seeds: seeds, table: table}
+}
+
+// hash mixes seed into the hash input itself, rather than XORing it onto
+// the output, so each row gets an independent FNV state and collisions
+// don't correlate across rows the way they would with a post-hash XOR.
+func (c *CountMinSketch) hash(key string, seed uint32) uint32 {
+	h := fnv.New32a()
+	var seedBytes [4]byte
+	seedBytes[0] = byte(seed)
+	seedBytes[1] = byte(seed >> 8)
+	seedBytes[2] = byte(seed >> 16)
+	seedBytes[3] = byte(seed >> 24)
+	h.Write(seedBytes[:])
+	h.Write([]byte(key))
+	return h.Sum32() % c.width
+}
+
+// Add increments the counter for key in every row of the sketch.
+func (c *CountMinSketch) Add(key string) {
+	for i := uint32(0); i < c.depth; i++ {
+		idx := c.hash(key, c.seeds[i])
+		c.table[i][idx]++
+	}
+}
+
+// Estimate returns the minimum counter across all rows for key, which is
+// guaranteed to never under-estimate the real count.
+func (c *CountMinSketch) Estimate(key string) uint32 {
+	min := uint32(math.MaxUint32)
+	for i := uint32(0); i < c.depth; i++ {
+		idx := c.hash(key, c.seeds[i])
+		if c.table[i][idx] < min {
+			min = c.table[i][idx]
+		}
+	}
+	return min
+}
+
+// ssEntry is a tracked key in a StreamSummary, along with the over-counting
+// error introduced when it replaced an evicted entry.
+type ssEntry struct {
+	key   string
+	count uint32
+	error uint32
+}
+
+// StreamSummary implements the Space-Saving algorithm: it keeps exact counts
+// for at most `capacity` keys and approximates the rest by recycling the
+// slot with the smallest count, which bounds memory to O(capacity).
+type StreamSummary struct {
+	capacity int
+	entries  map[string]*ssEntry
+}
+
+func NewStreamSummary(capacity int) *StreamSummary {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &StreamSummary{capacity: capacity, entries: make(map[string]*ssEntry)}
+}
+
+// Offer records one occurrence of key and reports whether key was not
+// already being tracked (i.e. it is new to the summary, whether because
+// there was free capacity or because it replaced an evicted entry).
+func (s *StreamSummary) Offer(key string) bool {
+	if e, ok := s.entries[key]; ok {
+		e.count++
+		return false
+	}
+
+	if len(s.entries) < s.capacity {
+		s.entries[key] = &ssEntry{key: key, count: 1}
+		return true
+	}
+
+	// stream-summary is full: evict the minimum-count entry and inherit its
+	// count plus one, remembering the possible over-count as `error`
+	var min *ssEntry
+	for _, e := range s.entries {
+		if min == nil || e.count < min.count {
+			min = e
+		}
+	}
+	delete(s.entries, min.key)
+	s.entries[key] = &ssEntry{key: key, count: min.count + 1, error: min.count}
+	return true
+}
+
+// Top returns the tracked entries sorted by descending count, capped at n.
+func (s *StreamSummary) Top(n int) []ssEntry {
+	all := make([]ssEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		all = append(all, *e)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].count > all[j].count })
+
+	if n > 0 && n < len(all) {
+		all = all[:n]
+	}
+	return all
+}
+
+// HyperLogLog estimates the number of distinct keys observed in a stream
+// using a constant-size register array, so unique-count gauges stay bounded
+// even when millions of distinct qnames are seen.
+type HyperLogLog struct {
+	registers []uint8
+	m         uint64
+	b         uint
+}
+
+// NewHyperLogLog allocates 2^precision registers (precision is typically
+// between 4 and 16, trading memory for estimation accuracy).
+func NewHyperLogLog(precision uint) *HyperLogLog {
+	if precision == 0 {
+		precision = 1
+	}
+	m := uint64(1) << precision
+	return &HyperLogLog{registers: make([]uint8, m), m: m, b: precision}
+}
+
+func (h *HyperLogLog) hash64(key string) uint64 {
+	hh := fnv.New64a()
+	hh.Write([]byte(key))
+
+	// FNV-1a leaves the top bits (used as the register index) poorly mixed
+	// for short, similar keys, so finish with the murmur3 fmix64 avalanche
+	v := hh.Sum64()
+	v ^= v >> 33
+	v *= 0xff51afd7ed558ccd
+	v ^= v >> 33
+	v *= 0xc4ceb9fe1a85ec53
+	v ^= v >> 33
+	return v
+}
+
+// Add records one observation of key.
+func (h *HyperLogLog) Add(key string) {
+	hv := h.hash64(key)
+	idx := hv >> (64 - h.b)
+	w := hv << h.b
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+	if rho > h.registers[idx] {
+		h.registers[idx] = rho
+	}
+}
+
+// Estimate returns the approximate number of distinct keys added so far.
+func (h *HyperLogLog) Estimate() uint64 {
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1.0 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/float64(h.m))
+	estimate := alpha * float64(h.m) * float64(h.m) / sum
+
+	// small-range correction: linear counting when many registers are empty
+	if estimate <= 2.5*float64(h.m) && zeros > 0 {
+		estimate = float64(h.m) * math.Log(float64(h.m)/float64(zeros))
+	}
+
+	return uint64(estimate)
+}
@@ -0,0 +1,111 @@
+package loggers
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// zipfianSample generates n samples over `items` distinct keys following a
+// Zipfian distribution, skewed so that low-index keys dominate the stream —
+// this is representative of qname popularity on a real resolver.
+func zipfianSample(n int, items uint64) []string {
+	r := rand.New(rand.NewSource(42))
+	zipf := rand.NewZipf(r, 1.1, 1.0, items-1)
+
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		keys[i] = "key-" + string(rune('a'+zipf.Uint64()%26)) + string(rune('0'+(zipf.Uint64()/26)%10))
+	}
+	return keys
+}
+
+func TestCountMinSketchErrorBound(t *testing.T) {
+	const (
+		width   = 2048
+		depth   = 4
+		samples = 50000
+	)
+
+	keys := zipfianSample(samples, 260)
+	exact := make(map[string]uint32, 260)
+	cms := NewCountMinSketch(width, depth)
+
+	for _, k := range keys {
+		exact[k]++
+		cms.Add(k)
+	}
+
+	// Count-Min Sketch never under-estimates, and over-estimates by at most
+	// samples/width per row (the standard CMS error bound)
+	maxError := uint32(math.Ceil(float64(samples) / float64(width)))
+
+	for k, want := range exact {
+		got := cms.Estimate(k)
+		if got < want {
+			t.Fatalf("Estimate(%q) = %d, want >= exact count %d (CMS must never under-count)", k, got, want)
+		}
+		if got > want+maxError {
+			t.Fatalf("Estimate(%q) = %d, exact = %d, exceeds error bound of %d", k, got, want, maxError)
+		}
+	}
+}
+
+func TestStreamSummaryTopK(t *testing.T) {
+	ss := NewStreamSummary(3)
+
+	// "hot" key seen far more than anything else should always survive eviction
+	for i := 0; i < 100; i++ {
+		ss.Offer("hot")
+	}
+	for i := 0; i < 5; i++ {
+		ss.Offer("warm")
+	}
+	for _, k := range []string{"cold1", "cold2", "cold3", "cold4", "cold5"} {
+		ss.Offer(k)
+	}
+
+	top := ss.Top(3)
+	if len(top) != 3 {
+		t.Fatalf("Top(3) returned %d entries, want 3", len(top))
+	}
+	if top[0].key != "hot" || top[0].count != 100 {
+		t.Fatalf("Top(3)[0] = %+v, want hot/100", top[0])
+	}
+}
+
+func TestStreamSummaryOfferReportsNewKeys(t *testing.T) {
+	ss := NewStreamSummary(2)
+
+	if isNew := ss.Offer("a"); !isNew {
+		t.Fatalf("Offer(a) on empty summary should report a new key")
+	}
+	if isNew := ss.Offer("a"); isNew {
+		t.Fatalf("Offer(a) on an already-tracked key should not report new")
+	}
+	if isNew := ss.Offer("b"); !isNew {
+		t.Fatalf("Offer(b) with free capacity should report a new key")
+	}
+	// summary is now full (capacity 2); offering a third key evicts the
+	// minimum entry and should still report new
+	if isNew := ss.Offer("c"); !isNew {
+		t.Fatalf("Offer(c) after eviction should report a new key")
+	}
+}
+
+func TestHyperLogLogEstimate(t *testing.T) {
+	const uniqueKeys = 10000
+
+	hll := NewHyperLogLog(14)
+	for i := 0; i < uniqueKeys; i++ {
+		hll.Add("domain-" + string(rune(i%26)) + string(rune((i/26)%26)) + string(rune((i/676)%26)))
+	}
+
+	estimate := hll.Estimate()
+	// standard error for precision 14 is ~0.8%; allow generous headroom
+	lower := uint64(float64(uniqueKeys) * 0.9)
+	upper := uint64(float64(uniqueKeys) * 1.1)
+	if estimate < lower || estimate > upper {
+		t.Fatalf("Estimate() = %d, want within [%d, %d] of %d unique keys", estimate, lower, upper, uniqueKeys)
+	}
+}
@@ -0,0 +1,63 @@
+package loggers
+
+// SlidingWindowCounter tracks per-key hit counts over a fixed time window
+// using N rotating buckets, so a key that "won" long ago ages out instead
+// of dominating the gauges forever. Record() always writes into the
+// current bucket; Rotate() advances to the next bucket, clearing the
+// oldest one out of the window.
+type SlidingWindowCounter struct {
+	buckets []map[string]int
+	current int
+}
+
+// NewSlidingWindowCounter allocates a counter with `numBuckets` rotating
+// buckets; callers typically rotate one bucket every
+// WindowSeconds/numBuckets so the total window spans WindowSeconds.
+func NewSlidingWindowCounter(numBuckets int) *SlidingWindowCounter {
+	if numBuckets <= 0 {
+		numBuckets = 1
+	}
+
+	buckets := make([]map[string]int, numBuckets)
+	for i := range buckets {
+		buckets[i] = make(map[string]int)
+	}
+
+	return &SlidingWindowCounter{buckets: buckets}
+}
+
+// Record increments key's hit count in the current bucket and returns the
+// summed count for key across the whole window.
+func (s *SlidingWindowCounter) Record(key string) int {
+	s.buckets[s.current][key] += 1
+	return s.Get(key)
+}
+
+// Get returns the hit count for key summed across all buckets in the window.
+func (s *SlidingWindowCounter) Get(key string) int {
+	total := 0
+	for _, b := range s.buckets {
+		total += b[key]
+	}
+	return total
+}
+
+// All returns the hit count for every key currently tracked anywhere in
+// the window.
+func (s *SlidingWindowCounter) All() map[string]int {
+	totals := make(map[string]int)
+	for _, b := range s.buckets {
+		for key, hits := range b {
+			totals[key] += hits
+		}
+	}
+	return totals
+}
+
+// Rotate advances to the next bucket, clearing it out so it can accumulate
+// the newest slice of the window. The bucket being reused is the oldest
+// one still in the window, which is how entries age out.
+func (s *SlidingWindowCounter) Rotate() {
+	s.current = (s.current + 1) % len(s.buckets)
+	s.buckets[s.current] = make(map[string]int)
+}
@@ -0,0 +1,60 @@
+package loggers
+
+import "testing"
+
+func TestSlidingWindowCounterRecordAndGet(t *testing.T) {
+	w := NewSlidingWindowCounter(3)
+
+	w.Record("a")
+	w.Record("a")
+	w.Record("b")
+
+	if got := w.Get("a"); got != 2 {
+		t.Fatalf("Get(a) = %d, want 2", got)
+	}
+	if got := w.Get("b"); got != 1 {
+		t.Fatalf("Get(b) = %d, want 1", got)
+	}
+	if got := w.Get("missing"); got != 0 {
+		t.Fatalf("Get(missing) = %d, want 0", got)
+	}
+}
+
+func TestSlidingWindowCounterAgesOutOldHits(t *testing.T) {
+	w := NewSlidingWindowCounter(3)
+
+	// "a" wins big in the oldest bucket, which should age out after 3 rotations
+	w.Record("a")
+	w.Record("a")
+	w.Record("a")
+	w.Rotate()
+
+	w.Record("b")
+	w.Rotate()
+
+	w.Record("b")
+	w.Rotate()
+
+	// after 3 rotations, the bucket containing "a"'s hits has cycled back
+	// around and been cleared, so only "b"'s hits remain in the window
+	if got := w.Get("a"); got != 0 {
+		t.Fatalf("Get(a) = %d, want 0 once its bucket has aged out of the window", got)
+	}
+	if got := w.Get("b"); got != 2 {
+		t.Fatalf("Get(b) = %d, want 2", got)
+	}
+}
+
+func TestSlidingWindowCounterAll(t *testing.T) {
+	w := NewSlidingWindowCounter(2)
+
+	w.Record("a")
+	w.Rotate()
+	w.Record("b")
+	w.Record("b")
+
+	all := w.All()
+	if all["a"] != 1 || all["b"] != 2 {
+		t.Fatalf("All() = %+v, want a:1 b:2", all)
+	}
+}